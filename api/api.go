@@ -0,0 +1,79 @@
+// Package api defines the flux API as seen by a client: fluxctl, fluxsvc's
+// own UI backend, or anything else driving a fluxd instance through
+// fluxsvc. http.NewClient and http.NewClientWithTransport are the
+// JSON-over-HTTP and pluggable-transport implementations of it.
+package api
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/jobs"
+)
+
+// ClientService is the set of calls a flux API client can make against an
+// instance. Every call has a context-accepting Ctx variant, so a caller can
+// cancel a slow ListImages or bound a PostRelease with a deadline; the
+// plain methods delegate to their Ctx counterpart with context.Background()
+// for callers that don't need that control. WatchReleaseCtx is the one
+// exception: it has no context-free variant, since there ctx is the only
+// way to stop a call a caller has abandoned (see its doc comment).
+type ClientService interface {
+	ListServices(inst flux.InstanceID, namespace string) ([]flux.ServiceStatus, error)
+	ListServicesCtx(ctx context.Context, inst flux.InstanceID, namespace string) ([]flux.ServiceStatus, error)
+
+	ListImages(inst flux.InstanceID, s flux.ServiceSpec) ([]flux.ImageStatus, error)
+	ListImagesCtx(ctx context.Context, inst flux.InstanceID, s flux.ServiceSpec) ([]flux.ImageStatus, error)
+
+	PostRelease(inst flux.InstanceID, s jobs.ReleaseJobParams) (jobs.JobID, error)
+	PostReleaseCtx(ctx context.Context, inst flux.InstanceID, s jobs.ReleaseJobParams) (jobs.JobID, error)
+
+	GetRelease(inst flux.InstanceID, id jobs.JobID) (jobs.Job, error)
+	GetReleaseCtx(ctx context.Context, inst flux.InstanceID, id jobs.JobID) (jobs.Job, error)
+
+	// WatchReleaseCtx streams progress updates for a release job, so callers
+	// following it in real time don't have to poll GetRelease. The returned
+	// channel is closed once the job reaches a terminal state or ctx is
+	// canceled. Unlike the other calls here, there is no context-free
+	// variant: event delivery blocks on the returned channel, which a
+	// receiver can never close from its side, so ctx is the only way to
+	// stop an abandoned watch from leaking its connection and goroutine.
+	WatchReleaseCtx(ctx context.Context, inst flux.InstanceID, id jobs.JobID) (<-chan jobs.JobStatus, error)
+
+	Automate(inst flux.InstanceID, id flux.ServiceID) error
+	AutomateCtx(ctx context.Context, inst flux.InstanceID, id flux.ServiceID) error
+
+	Deautomate(inst flux.InstanceID, id flux.ServiceID) error
+	DeautomateCtx(ctx context.Context, inst flux.InstanceID, id flux.ServiceID) error
+
+	Lock(inst flux.InstanceID, id flux.ServiceID) error
+	LockCtx(ctx context.Context, inst flux.InstanceID, id flux.ServiceID) error
+
+	Unlock(inst flux.InstanceID, id flux.ServiceID) error
+	UnlockCtx(ctx context.Context, inst flux.InstanceID, id flux.ServiceID) error
+
+	History(inst flux.InstanceID, s flux.ServiceSpec) ([]flux.HistoryEntry, error)
+	HistoryCtx(ctx context.Context, inst flux.InstanceID, s flux.ServiceSpec) ([]flux.HistoryEntry, error)
+
+	GetConfig(inst flux.InstanceID) (flux.InstanceConfig, error)
+	GetConfigCtx(ctx context.Context, inst flux.InstanceID) (flux.InstanceConfig, error)
+
+	SetConfig(inst flux.InstanceID, config flux.UnsafeInstanceConfig) error
+	SetConfigCtx(ctx context.Context, inst flux.InstanceID, config flux.UnsafeInstanceConfig) error
+
+	GenerateDeployKey(inst flux.InstanceID) error
+	GenerateDeployKeyCtx(ctx context.Context, inst flux.InstanceID) error
+
+	Status(inst flux.InstanceID) (flux.Status, error)
+	StatusCtx(ctx context.Context, inst flux.InstanceID) (flux.Status, error)
+
+	// Collector exposes this client's Prometheus metrics, so a caller that
+	// doesn't use the default registry can register them with its own.
+	// It's on the interface, rather than a method only reachable on the
+	// concrete type http.NewClient returns, so that code holding a
+	// ClientService doesn't need an import of the http package just to
+	// wire up metrics.
+	Collector() prometheus.Collector
+}
@@ -1,50 +1,96 @@
 package http
 
 import (
-	"bytes"
-	"encoding/json"
-	"io/ioutil"
+	"context"
 	"net/http"
-	"strings"
+	"net/url"
 
 	"github.com/gorilla/mux"
-	"github.com/pkg/errors"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/api"
-	"github.com/weaveworks/flux/http/error"
 	"github.com/weaveworks/flux/jobs"
 )
 
+// client is a transport-agnostic implementation of api.ClientService: all of
+// the route-specific methods below just shape their arguments into a call
+// against a Transport, which is what actually knows how to get bytes to and
+// from fluxd.
 type client struct {
-	client   *http.Client
-	token    flux.Token
-	router   *mux.Router
-	endpoint string
-}
-
-func NewClient(c *http.Client, router *mux.Router, endpoint string, t flux.Token) api.ClientService {
-	return &client{
-		client:   c,
-		token:    t,
-		router:   router,
-		endpoint: endpoint,
+	transport Transport
+}
+
+// ClientOption customizes the httpTransport built by NewClient.
+type ClientOption func(*httpTransport)
+
+// WithRetryPolicy overrides the default retry policy NewClient uses for
+// transient failures. Pass NoRetries to get the old, no-retry behaviour
+// back, e.g. in tests that want a single deterministic request.
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(t *httpTransport) {
+		t.retryPolicy = p
+	}
+}
+
+// NewClient creates an api.ClientService backed by the given HTTP client,
+// talking JSON-over-HTTP to endpoint. This is the transport fluxctl and
+// fluxsvc have always used; for other transports (e.g. gRPC) use
+// NewClientWithTransport.
+func NewClient(c *http.Client, router *mux.Router, endpoint string, t flux.Token, opts ...ClientOption) api.ClientService {
+	transport := &httpTransport{
+		client:      c,
+		token:       t,
+		router:      router,
+		endpoint:    endpoint,
+		retryPolicy: DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(transport)
 	}
+	return NewClientWithTransport(transport)
 }
 
-func (c *client) ListServices(_ flux.InstanceID, namespace string) ([]flux.ServiceStatus, error) {
+// NewClientWithTransport creates an api.ClientService backed by an arbitrary
+// Transport, so that callers can run the fluxd<->fluxsvc link over
+// something other than JSON-over-HTTP (a gRPC transport, a NATS transport,
+// or an in-process one for tests) without any of the route logic below
+// changing. Calls made through t are recorded in the client's Prometheus
+// metrics regardless of which Transport this is, via InstrumentTransport.
+func NewClientWithTransport(t Transport) api.ClientService {
+	return &client{transport: InstrumentTransport(t)}
+}
+
+// The *Ctx methods below are the real implementations: they thread ctx all
+// the way down to http.NewRequestWithContext, so a caller can cancel a slow
+// ListImages or bound a PostRelease with a deadline. The non-Ctx methods on
+// api.ClientService delegate to them with context.Background(), for callers
+// that don't need that control.
+
+func (c *client) ListServices(inst flux.InstanceID, namespace string) ([]flux.ServiceStatus, error) {
+	return c.ListServicesCtx(context.Background(), inst, namespace)
+}
+
+func (c *client) ListServicesCtx(ctx context.Context, inst flux.InstanceID, namespace string) ([]flux.ServiceStatus, error) {
 	var res []flux.ServiceStatus
-	err := c.get(&res, "ListServices", "namespace", namespace)
+	err := c.get(ctx, inst, &res, "ListServices", "namespace", namespace)
 	return res, err
 }
 
-func (c *client) ListImages(_ flux.InstanceID, s flux.ServiceSpec) ([]flux.ImageStatus, error) {
+func (c *client) ListImages(inst flux.InstanceID, s flux.ServiceSpec) ([]flux.ImageStatus, error) {
+	return c.ListImagesCtx(context.Background(), inst, s)
+}
+
+func (c *client) ListImagesCtx(ctx context.Context, inst flux.InstanceID, s flux.ServiceSpec) ([]flux.ImageStatus, error) {
 	var res []flux.ImageStatus
-	err := c.get(&res, "ListImages", "service", string(s))
+	err := c.get(ctx, inst, &res, "ListImages", "service", string(s))
 	return res, err
 }
 
-func (c *client) PostRelease(_ flux.InstanceID, s jobs.ReleaseJobParams) (jobs.JobID, error) {
+func (c *client) PostRelease(inst flux.InstanceID, s jobs.ReleaseJobParams) (jobs.JobID, error) {
+	return c.PostReleaseCtx(context.Background(), inst, s)
+}
+
+func (c *client) PostReleaseCtx(ctx context.Context, inst flux.InstanceID, s jobs.ReleaseJobParams) (jobs.JobID, error) {
 	args := []string{"image", string(s.ImageSpec), "kind", string(s.Kind)}
 	for _, spec := range s.ServiceSpecs {
 		args = append(args, "service", string(spec))
@@ -54,151 +100,127 @@ func (c *client) PostRelease(_ flux.InstanceID, s jobs.ReleaseJobParams) (jobs.J
 	}
 
 	var resp postReleaseResponse
-	err := c.postWithResp(&resp, "PostRelease", nil, args...)
+	err := c.postWithResp(ctx, inst, &resp, "PostRelease", nil, args...)
 	return resp.ReleaseID, err
 }
 
-func (c *client) GetRelease(_ flux.InstanceID, id jobs.JobID) (jobs.Job, error) {
+func (c *client) GetRelease(inst flux.InstanceID, id jobs.JobID) (jobs.Job, error) {
+	return c.GetReleaseCtx(context.Background(), inst, id)
+}
+
+func (c *client) GetReleaseCtx(ctx context.Context, inst flux.InstanceID, id jobs.JobID) (jobs.Job, error) {
 	var res jobs.Job
-	err := c.get(&res, "GetRelease", "id", string(id))
+	err := c.get(ctx, inst, &res, "GetRelease", "id", string(id))
 	return res, err
 }
 
-func (c *client) Automate(_ flux.InstanceID, id flux.ServiceID) error {
-	return c.post("Automate", "service", string(id))
+func (c *client) Automate(inst flux.InstanceID, id flux.ServiceID) error {
+	return c.AutomateCtx(context.Background(), inst, id)
+}
+
+func (c *client) AutomateCtx(ctx context.Context, inst flux.InstanceID, id flux.ServiceID) error {
+	return c.post(ctx, inst, "Automate", "service", string(id))
+}
+
+func (c *client) Deautomate(inst flux.InstanceID, id flux.ServiceID) error {
+	return c.DeautomateCtx(context.Background(), inst, id)
+}
+
+func (c *client) DeautomateCtx(ctx context.Context, inst flux.InstanceID, id flux.ServiceID) error {
+	return c.post(ctx, inst, "Deautomate", "service", string(id))
+}
+
+func (c *client) Lock(inst flux.InstanceID, id flux.ServiceID) error {
+	return c.LockCtx(context.Background(), inst, id)
+}
+
+func (c *client) LockCtx(ctx context.Context, inst flux.InstanceID, id flux.ServiceID) error {
+	return c.post(ctx, inst, "Lock", "service", string(id))
 }
 
-func (c *client) Deautomate(_ flux.InstanceID, id flux.ServiceID) error {
-	return c.post("Deautomate", "service", string(id))
+func (c *client) Unlock(inst flux.InstanceID, id flux.ServiceID) error {
+	return c.UnlockCtx(context.Background(), inst, id)
 }
 
-func (c *client) Lock(_ flux.InstanceID, id flux.ServiceID) error {
-	return c.post("Lock", "service", string(id))
+func (c *client) UnlockCtx(ctx context.Context, inst flux.InstanceID, id flux.ServiceID) error {
+	return c.post(ctx, inst, "Unlock", "service", string(id))
 }
 
-func (c *client) Unlock(_ flux.InstanceID, id flux.ServiceID) error {
-	return c.post("Unlock", "service", string(id))
+func (c *client) History(inst flux.InstanceID, s flux.ServiceSpec) ([]flux.HistoryEntry, error) {
+	return c.HistoryCtx(context.Background(), inst, s)
 }
 
-func (c *client) History(_ flux.InstanceID, s flux.ServiceSpec) ([]flux.HistoryEntry, error) {
+func (c *client) HistoryCtx(ctx context.Context, inst flux.InstanceID, s flux.ServiceSpec) ([]flux.HistoryEntry, error) {
 	var res []flux.HistoryEntry
-	err := c.get(&res, "History", "service", string(s))
+	err := c.get(ctx, inst, &res, "History", "service", string(s))
 	return res, err
 }
 
-func (c *client) GetConfig(_ flux.InstanceID) (flux.InstanceConfig, error) {
+func (c *client) GetConfig(inst flux.InstanceID) (flux.InstanceConfig, error) {
+	return c.GetConfigCtx(context.Background(), inst)
+}
+
+func (c *client) GetConfigCtx(ctx context.Context, inst flux.InstanceID) (flux.InstanceConfig, error) {
 	var res flux.InstanceConfig
-	err := c.get(&res, "GetConfig")
+	err := c.get(ctx, inst, &res, "GetConfig")
 	return res, err
 }
 
-func (c *client) SetConfig(_ flux.InstanceID, config flux.UnsafeInstanceConfig) error {
-	return c.postWithBody("SetConfig", config)
+func (c *client) SetConfig(inst flux.InstanceID, config flux.UnsafeInstanceConfig) error {
+	return c.SetConfigCtx(context.Background(), inst, config)
+}
+
+func (c *client) SetConfigCtx(ctx context.Context, inst flux.InstanceID, config flux.UnsafeInstanceConfig) error {
+	return c.postWithBody(ctx, inst, "SetConfig", config)
+}
+
+func (c *client) GenerateDeployKey(inst flux.InstanceID) error {
+	return c.GenerateDeployKeyCtx(context.Background(), inst)
+}
+
+func (c *client) GenerateDeployKeyCtx(ctx context.Context, inst flux.InstanceID) error {
+	return c.post(ctx, inst, "GenerateDeployKeys")
 }
 
-func (c *client) GenerateDeployKey(_ flux.InstanceID) error {
-	return c.post("GenerateDeployKeys")
+func (c *client) Status(inst flux.InstanceID) (flux.Status, error) {
+	return c.StatusCtx(context.Background(), inst)
 }
 
-func (c *client) Status(_ flux.InstanceID) (flux.Status, error) {
+func (c *client) StatusCtx(ctx context.Context, inst flux.InstanceID) (flux.Status, error) {
 	var res flux.Status
-	err := c.get(&res, "Status")
+	err := c.get(ctx, inst, &res, "Status")
 	return res, err
 }
 
 // post is a simple query-param only post request
-func (c *client) post(route string, queryParams ...string) error {
-	return c.postWithBody(route, nil, queryParams...)
+func (c *client) post(ctx context.Context, inst flux.InstanceID, route string, queryParams ...string) error {
+	return c.postWithBody(ctx, inst, route, nil, queryParams...)
 }
 
 // postWithBody is a more complex post request, which includes a json-ified body.
 // If body is not nil, it is encoded to json before sending
-func (c *client) postWithBody(route string, body interface{}, queryParams ...string) error {
-	return c.postWithResp(nil, route, body, queryParams...)
+func (c *client) postWithBody(ctx context.Context, inst flux.InstanceID, route string, body interface{}, queryParams ...string) error {
+	return c.postWithResp(ctx, inst, nil, route, body, queryParams...)
 }
 
 // postWithResp is the full enchilada, it handles body and query-param
 // encoding, as well as decoding the response into the provided destination.
 // Note, the response will only be decoded into the dest if the len is > 0.
-func (c *client) postWithResp(dest interface{}, route string, body interface{}, queryParams ...string) error {
-	u, err := makeURL(c.endpoint, c.router, route, queryParams...)
-	if err != nil {
-		return errors.Wrap(err, "constructing URL")
-	}
-
-	var bodyBytes []byte
-	if body != nil {
-		bodyBytes, err = json.Marshal(body)
-		if err != nil {
-			return errors.Wrap(err, "encoding request body")
-		}
-	}
-
-	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(bodyBytes))
-	if err != nil {
-		return errors.Wrapf(err, "constructing request %s", u)
-	}
-	c.token.Set(req)
-
-	resp, err := c.executeRequest(req)
-	if err != nil {
-		return errors.Wrap(err, "executing HTTP request")
-	}
-	defer resp.Body.Close()
-
-	respBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return errors.Wrap(err, "decoding response from server")
-	}
-	if len(respBytes) <= 0 {
-		return nil
-	}
-	if err := json.Unmarshal(respBytes, &dest); err != nil {
-		return errors.Wrap(err, "decoding response from server")
-	}
-	return nil
+func (c *client) postWithResp(ctx context.Context, inst flux.InstanceID, dest interface{}, route string, body interface{}, queryParams ...string) error {
+	return c.transport.Invoke(ctx, inst, "POST", route, asParams(queryParams), body, dest)
 }
 
 // get executes a get request against the flux server. it unmarshals the response into dest.
-func (c *client) get(dest interface{}, route string, queryParams ...string) error {
-	u, err := makeURL(c.endpoint, c.router, route, queryParams...)
-	if err != nil {
-		return errors.Wrap(err, "constructing URL")
-	}
-
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return errors.Wrapf(err, "constructing request %s", u)
-	}
-	c.token.Set(req)
-
-	resp, err := c.executeRequest(req)
-	if err != nil {
-		return errors.Wrap(err, "executing HTTP request")
-	}
-	defer resp.Body.Close()
-
-	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
-		return errors.Wrap(err, "decoding response from server")
-	}
-	return nil
+func (c *client) get(ctx context.Context, inst flux.InstanceID, dest interface{}, route string, queryParams ...string) error {
+	return c.transport.Invoke(ctx, inst, "GET", route, asParams(queryParams), nil, dest)
 }
 
-func (c *client) executeRequest(req *http.Request) (*http.Response, error) {
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, errors.Wrap(err, "executing HTTP request")
-	}
-	switch resp.StatusCode {
-	case http.StatusOK:
-		return resp, nil
-	default:
-		buf, _ := ioutil.ReadAll(resp.Body)
-		body := strings.TrimSpace(string(buf))
-		return nil, &httperror.APIError{
-			StatusCode: resp.StatusCode,
-			Status:     resp.Status,
-			Body:       body,
-		}
+// asParams turns the alternating key/value pairs used throughout this file
+// into a url.Values, the form a Transport expects them in.
+func asParams(queryParams []string) url.Values {
+	params := url.Values{}
+	for i := 0; i+1 < len(queryParams); i += 2 {
+		params.Add(queryParams[i], queryParams[i+1])
 	}
+	return params
 }
@@ -0,0 +1,102 @@
+// Package httperror defines the error envelope the flux API server sends on
+// non-200 responses, and the concrete error values the client decodes it
+// into, so that scripting against the API doesn't mean string-matching
+// response bodies.
+package httperror
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// APIError is the fallback used when a response couldn't be decoded as an
+// Error envelope at all -- e.g. a 502 from an ingress controller in front of
+// fluxsvc, which never saw our handler.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Status, e.Body)
+}
+
+// HTTPStatusCode reports the response status code that produced this error,
+// for callers (e.g. request metrics) that want it without caring whether
+// the error decoded to an APIError or a typed Error.
+func (e *APIError) HTTPStatusCode() int { return e.StatusCode }
+
+// Code identifies the kind of failure an Error represents, so that callers
+// can distinguish them with errors.Is instead of matching on Message, which
+// is for humans and may change wording.
+type Code string
+
+const (
+	CodeServiceNotFound   Code = "service_not_found"
+	CodeReleaseInProgress Code = "release_in_progress"
+	CodeUnauthorized      Code = "unauthorized"
+	CodeValidation        Code = "validation"
+)
+
+// Error is the JSON envelope `{code, message, details, retryable}` that
+// server handlers emit for any failure they recognize, and the client
+// decodes back into a concrete Go error. Details carries field-level
+// context for CodeValidation (e.g. {"image": "not a valid image ref"}).
+type Error struct {
+	Code      Code              `json:"code"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	Retryable bool              `json:"retryable"`
+
+	// StatusCode is the HTTP status the envelope arrived with. It isn't
+	// part of the wire format -- Decode fills it in from the response, not
+	// from the JSON body -- so that callers have it regardless of which
+	// concrete error type a response decoded to.
+	StatusCode int `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return string(e.Code)
+}
+
+// HTTPStatusCode reports the response status code that produced this error.
+func (e *Error) HTTPStatusCode() int { return e.StatusCode }
+
+// Is lets errors.Is match sentinels like ErrServiceNotFound against any
+// Error with the same Code, regardless of Message or Details.
+func (e *Error) Is(target error) bool {
+	te, ok := target.(*Error)
+	return ok && te.Code == e.Code
+}
+
+// Sentinel errors for errors.Is; compare with these rather than Code
+// directly so callers don't need to import the Code constants themselves.
+var (
+	ErrServiceNotFound   = &Error{Code: CodeServiceNotFound, Message: "service not found"}
+	ErrReleaseInProgress = &Error{Code: CodeReleaseInProgress, Message: "a release is already in progress"}
+	ErrUnauthorized      = &Error{Code: CodeUnauthorized, Message: "unauthorized"}
+)
+
+// NewValidationError builds an errors.As-able validation failure carrying
+// field-level detail, e.g. NewValidationError(map[string]string{"image":
+// "not a valid image ref"}).
+func NewValidationError(details map[string]string) *Error {
+	return &Error{Code: CodeValidation, Message: "validation failed", Details: details, Retryable: false}
+}
+
+// Decode turns a response body into the most specific error it can: an
+// *Error if body is a valid envelope, or an *APIError as a fallback for
+// responses (like a 502 from an intermediary) that never went through a
+// flux handler.
+func Decode(statusCode int, status string, body []byte) error {
+	var e Error
+	if err := json.Unmarshal(body, &e); err == nil && e.Code != "" {
+		e.StatusCode = statusCode
+		return &e
+	}
+	return &APIError{StatusCode: statusCode, Status: status, Body: string(body)}
+}
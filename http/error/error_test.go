@@ -0,0 +1,53 @@
+package httperror
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeTypedEnvelope(t *testing.T) {
+	body := []byte(`{"code":"service_not_found","message":"no such service","retryable":false}`)
+	err := Decode(404, "404 Not Found", body)
+
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Decode returned %T, want *Error", err)
+	}
+	if e.Code != CodeServiceNotFound {
+		t.Errorf("Code = %q, want %q", e.Code, CodeServiceNotFound)
+	}
+	if e.HTTPStatusCode() != 404 {
+		t.Errorf("HTTPStatusCode() = %d, want 404", e.HTTPStatusCode())
+	}
+}
+
+func TestDecodeFallsBackToAPIError(t *testing.T) {
+	body := []byte("<html>502 Bad Gateway</html>")
+	err := Decode(502, "502 Bad Gateway", body)
+
+	e, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("Decode returned %T, want *APIError", err)
+	}
+	if e.HTTPStatusCode() != 502 {
+		t.Errorf("HTTPStatusCode() = %d, want 502", e.HTTPStatusCode())
+	}
+}
+
+func TestDecodeEmptyCodeFallsBackToAPIError(t *testing.T) {
+	// Valid JSON, but not our envelope -- Code is empty, so this should not
+	// be mistaken for a typed Error.
+	body := []byte(`{"message":"something else entirely"}`)
+	err := Decode(500, "500 Internal Server Error", body)
+
+	if _, ok := err.(*APIError); !ok {
+		t.Fatalf("Decode returned %T, want *APIError", err)
+	}
+}
+
+func TestErrorIsMatchesByCode(t *testing.T) {
+	err := Decode(404, "404 Not Found", []byte(`{"code":"service_not_found","message":"whatever"}`))
+	if !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("expected err to match ErrServiceNotFound via Is")
+	}
+}
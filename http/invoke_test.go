@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/flux"
+)
+
+// newTestTransport builds an httpTransport against srv with a single named
+// route that takes no path or query variables, so tests can drive
+// Invoke's retry loop without depending on how real routes are registered.
+func newTestTransport(endpoint string, policy RetryPolicy) *httpTransport {
+	router := mux.NewRouter()
+	router.NewRoute().Name("TestRoute").Methods("GET", "POST").Path("/test")
+	return &httpTransport{
+		client:      http.DefaultClient,
+		token:       flux.Token(""),
+		router:      router,
+		endpoint:    endpoint,
+		retryPolicy: policy,
+	}
+}
+
+func TestInvokeRetriesOn503ThenSucceeds(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	transport := newTestTransport(srv.URL, RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	err := transport.Invoke(context.Background(), flux.InstanceID("inst"), "POST", "TestRoute", url.Values{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Invoke returned error: %v", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Errorf("server saw %d requests, want 3 (2 failures + 1 success)", got)
+	}
+}
+
+func TestInvokeHonorsRetryAfterOverDefaultBackoff(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	// With BaseDelay/MaxDelay at 1s, our own full-jitter backoff would draw
+	// a sleep from [0, 1s), past the deadline below four times in five, if
+	// Retry-After weren't overriding it.
+	transport := newTestTransport(srv.URL, RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Second})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- transport.Invoke(context.Background(), flux.InstanceID("inst"), "POST", "TestRoute", url.Values{}, nil, nil)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Invoke returned error: %v", err)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Invoke did not return promptly; Retry-After does not appear to have been honored")
+	}
+}
+
+func TestInvokeIdempotencyKeyStableAcrossRetriesOnPOST(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		n := len(keys)
+		mu.Unlock()
+		if n <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	transport := newTestTransport(srv.URL, RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	if err := transport.Invoke(context.Background(), flux.InstanceID("inst"), "POST", "TestRoute", url.Values{}, nil, nil); err != nil {
+		t.Fatalf("Invoke returned error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(keys) != 3 {
+		t.Fatalf("server saw %d requests, want 3", len(keys))
+	}
+	for _, k := range keys {
+		if k == "" {
+			t.Fatal("POST request had no Idempotency-Key header")
+		}
+		if k != keys[0] {
+			t.Fatalf("Idempotency-Key changed across retries: %q != %q", k, keys[0])
+		}
+	}
+}
+
+func TestInvokeNoIdempotencyKeyOnGET(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if k := r.Header.Get("Idempotency-Key"); k != "" {
+			t.Errorf("GET request carried an Idempotency-Key header: %q", k)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	transport := newTestTransport(srv.URL, NoRetries)
+	if err := transport.Invoke(context.Background(), flux.InstanceID("inst"), "GET", "TestRoute", url.Values{}, nil, nil); err != nil {
+		t.Fatalf("Invoke returned error: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/flux"
+)
+
+const metricsNamespace = "flux"
+const metricsSubsystem = "client"
+
+var (
+	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "request_count",
+		Help:      "Number of API requests made, by route, status code and instance.",
+	}, []string{"route", "status_code", "instance_id"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "request_duration_seconds",
+		Help:      "Duration of API requests, by route, status code and instance.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "status_code", "instance_id"})
+)
+
+// collectors bundles several prometheus.Collectors behind a single one, so
+// Collector can hand callers one value to register.
+type collectors []prometheus.Collector
+
+func (cs collectors) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range cs {
+		c.Describe(ch)
+	}
+}
+
+func (cs collectors) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range cs {
+		c.Collect(ch)
+	}
+}
+
+// Collector exposes the client's Prometheus metrics so that callers who
+// don't use the default registry can register them with their own.
+func Collector() prometheus.Collector {
+	return collectors{requestCount, requestDuration}
+}
+
+// Collector exposes c's Prometheus metrics, the same ones every client
+// shares -- instrumentation is a property of the Transport underneath a
+// client, not of any one client value, so this is equivalent to calling the
+// package-level Collector(). It exists as a method too so that callers
+// holding an api.ClientService (which is all NewClient and
+// NewClientWithTransport return) don't need a side import of this package
+// just to register metrics.
+func (c *client) Collector() prometheus.Collector {
+	return Collector()
+}
+
+// maxInstanceLabels bounds how many distinct instance_id label values
+// requestCount/requestDuration will track. Instance IDs are chosen by
+// whoever operates fluxsvc, so without a bound a tenant that churns through
+// many short-lived instances can grow these metrics without limit; once the
+// cap is hit, further instances are recorded under "overflow" rather than
+// given their own label value.
+const maxInstanceLabels = 200
+
+var (
+	instanceLabelsMu sync.Mutex
+	instanceLabels   = make(map[flux.InstanceID]struct{}, maxInstanceLabels)
+)
+
+func boundedInstanceLabel(inst flux.InstanceID) string {
+	instanceLabelsMu.Lock()
+	defer instanceLabelsMu.Unlock()
+	if _, ok := instanceLabels[inst]; ok {
+		return string(inst)
+	}
+	if len(instanceLabels) >= maxInstanceLabels {
+		return "overflow"
+	}
+	instanceLabels[inst] = struct{}{}
+	return string(inst)
+}
+
+// observeRequest records one completed call against route, for SLO-quality
+// visibility into which flux API routes are slow or erroring.
+func observeRequest(inst flux.InstanceID, route string, statusCode int, start time.Time) {
+	labels := prometheus.Labels{
+		"route":       route,
+		"status_code": strconv.Itoa(statusCode),
+		"instance_id": boundedInstanceLabel(inst),
+	}
+	requestCount.With(labels).Inc()
+	requestDuration.With(labels).Observe(time.Since(start).Seconds())
+}
+
+// instrumentedTransport wraps a Transport so every call made through it is
+// recorded via observeRequest, regardless of which wire format the
+// underlying Transport speaks -- this is what lets the gRPC Transport get
+// the same request metrics the original HTTP one always has.
+type instrumentedTransport struct {
+	next Transport
+}
+
+// InstrumentTransport wraps t so that calls made through it are recorded in
+// the client's Prometheus metrics. NewClientWithTransport applies this to
+// every Transport it's given, so callers don't usually need it directly;
+// it's exported for tests and for anyone constructing a Transport outside
+// of this package's own constructors.
+func InstrumentTransport(t Transport) Transport {
+	return &instrumentedTransport{next: t}
+}
+
+func (t *instrumentedTransport) Invoke(ctx context.Context, inst flux.InstanceID, method, route string, params url.Values, body, dest interface{}) error {
+	start := time.Now()
+	err := t.next.Invoke(ctx, inst, method, route, params, body, dest)
+	observeRequest(inst, route, statusCodeOf(err), start)
+	return err
+}
+
+func (t *instrumentedTransport) Stream(ctx context.Context, inst flux.InstanceID, route string, params url.Values, resumeToken string) (io.ReadCloser, error) {
+	start := time.Now()
+	body, err := t.next.Stream(ctx, inst, route, params, resumeToken)
+	observeRequest(inst, route, statusCodeOf(err), start)
+	return body, err
+}
+
+// statusCodeOf extracts the status code a Transport call failed with, by
+// unwrapping err looking for the HTTPStatusCode() method both httperror
+// types carry -- the errors.Wrap calls in httpTransport add layers that
+// don't themselves implement it, so a single type assertion on err isn't
+// enough. A nil error counts as 200; an error with no status code anywhere
+// in its chain (a network error, a canceled context) counts as 0.
+func statusCodeOf(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	for e := err; e != nil; {
+		if coder, ok := e.(interface{ HTTPStatusCode() int }); ok {
+			return coder.HTTPStatusCode()
+		}
+		unwrapper, ok := e.(interface{ Unwrap() error })
+		if !ok {
+			return 0
+		}
+		e = unwrapper.Unwrap()
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/http/error"
+)
+
+func TestStatusCodeOfUnwrapsWrappedErrors(t *testing.T) {
+	decoded := httperror.Decode(404, "404 Not Found", []byte(`{"code":"service_not_found"}`))
+	wrapped := errors.Wrap(decoded, "executing watch request")
+
+	if got := statusCodeOf(wrapped); got != 404 {
+		t.Errorf("statusCodeOf(wrapped) = %d, want 404", got)
+	}
+}
+
+func TestStatusCodeOfNilIsOK(t *testing.T) {
+	if got := statusCodeOf(nil); got != http.StatusOK {
+		t.Errorf("statusCodeOf(nil) = %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestStatusCodeOfPlainErrorIsZero(t *testing.T) {
+	if got := statusCodeOf(errors.New("network unreachable")); got != 0 {
+		t.Errorf("statusCodeOf(plain error) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how httpTransport retries a request that failed
+// transiently -- a network error, or a 429/502/503 from an ingress in front
+// of fluxsvc. Delays follow the AWS "FullJitter" strategy: each attempt
+// sleeps a random duration between zero and min(MaxDelay, BaseDelay*2^n),
+// so that a thundering herd of retrying clients spreads out instead of
+// retrying in lockstep.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times a request is tried,
+	// including the first. A value of 1 (or less) disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used by NewClient unless overridden with
+// WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// NoRetries disables retries altogether; tests that want deterministic,
+// single-shot requests can pass this to WithRetryPolicy.
+var NoRetries = RetryPolicy{MaxAttempts: 1}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns how long to sleep before the given retry attempt
+// (0-indexed: 0 is the delay before the second try), following full jitter.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	max := p.BaseDelay << uint(attempt)
+	if max <= 0 || max > p.MaxDelay {
+		max = p.MaxDelay
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(mathrand.Int63n(int64(max)))
+}
+
+// newIdempotencyKey generates a client-side key identifying one logical
+// POST, so that retries of it can be deduped by the server.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing is effectively unrecoverable; fall back to a
+		// key that is still unique enough to avoid accidental collisions.
+		return strconv.FormatInt(int64(mathrand.Uint64()), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// isRetryableStatus reports whether a response with this status code is
+// worth retrying: rate limiting, or an intermediary/backend that is
+// temporarily unavailable.
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter parses a Retry-After header in the seconds form (the form
+// ingress controllers and fluxsvc itself emit), returning ok=false if it is
+// absent or malformed so the caller falls back to its own backoff.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyAttempts(t *testing.T) {
+	cases := []struct {
+		policy RetryPolicy
+		want   int
+	}{
+		{RetryPolicy{MaxAttempts: 4}, 4},
+		{RetryPolicy{MaxAttempts: 1}, 1},
+		{RetryPolicy{MaxAttempts: 0}, 1},
+		{RetryPolicy{MaxAttempts: -1}, 1},
+	}
+	for _, c := range cases {
+		if got := c.policy.attempts(); got != c.want {
+			t.Errorf("RetryPolicy%+v.attempts() = %d, want %d", c.policy, got, c.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffStaysWithinBounds(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+	for attempt := 0; attempt < 10; attempt++ {
+		d := policy.backoff(attempt)
+		if d < 0 || d > policy.MaxDelay {
+			t.Fatalf("backoff(%d) = %v, want within [0, %v]", attempt, d, policy.MaxDelay)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffCapsAtMaxDelay(t *testing.T) {
+	// BaseDelay<<attempt overflows MaxDelay almost immediately with these
+	// values, so every call should be clamped rather than growing unbounded.
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 2 * time.Second}
+	for attempt := 0; attempt < 5; attempt++ {
+		if d := policy.backoff(attempt); d > policy.MaxDelay {
+			t.Fatalf("backoff(%d) = %v, want <= MaxDelay %v", attempt, d, policy.MaxDelay)
+		}
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	retryable := []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable}
+	for _, code := range retryable {
+		if !isRetryableStatus(code) {
+			t.Errorf("isRetryableStatus(%d) = false, want true", code)
+		}
+	}
+	notRetryable := []int{http.StatusOK, http.StatusNotFound, http.StatusInternalServerError}
+	for _, code := range notRetryable {
+		if isRetryableStatus(code) {
+			t.Errorf("isRetryableStatus(%d) = true, want false", code)
+		}
+	}
+}
+
+func TestRetryAfterParsesSeconds(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	d, ok := retryAfter(resp)
+	if !ok || d != 3*time.Second {
+		t.Errorf("retryAfter() = (%v, %v), want (3s, true)", d, ok)
+	}
+}
+
+func TestRetryAfterMissingOrMalformed(t *testing.T) {
+	cases := []http.Header{
+		{},
+		{"Retry-After": []string{"not-a-number"}},
+		{"Retry-After": []string{"-1"}},
+	}
+	for _, h := range cases {
+		if _, ok := retryAfter(&http.Response{Header: h}); ok {
+			t.Errorf("retryAfter(%v) ok = true, want false", h)
+		}
+	}
+}
+
+func TestNewIdempotencyKeyIsUniqueAndNonEmpty(t *testing.T) {
+	a := newIdempotencyKey()
+	b := newIdempotencyKey()
+	if a == "" || b == "" {
+		t.Fatal("newIdempotencyKey() returned an empty key")
+	}
+	if a == b {
+		t.Fatal("newIdempotencyKey() returned the same key twice")
+	}
+}
@@ -0,0 +1,204 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/http/error"
+)
+
+// Transport is how a client gets a call to a named route to fluxd and back,
+// independent of the wire format or network used to do it. The HTTP/JSON
+// transport below is the one fluxctl and fluxsvc have always used; other
+// implementations (gRPC, NATS, in-process for tests) can be swapped in via
+// NewClientWithTransport without any of api.ClientService's route logic
+// changing.
+type Transport interface {
+	// Invoke makes a single request/response call against route. If body is
+	// non-nil it is encoded as the request payload; if dest is non-nil the
+	// response payload is decoded into it. inst identifies the calling
+	// instance purely for instrumentation -- routes themselves don't vary
+	// by instance. Canceling ctx aborts the call in flight.
+	Invoke(ctx context.Context, inst flux.InstanceID, method, route string, params url.Values, body, dest interface{}) error
+
+	// Stream opens a long-lived call against route and returns the response
+	// for the caller to read newline-delimited events from until it is
+	// closed. resumeToken, if non-empty, asks the far end to resume a
+	// previously-dropped stream rather than starting over. Canceling ctx
+	// closes the stream.
+	Stream(ctx context.Context, inst flux.InstanceID, route string, params url.Values, resumeToken string) (io.ReadCloser, error)
+}
+
+// httpTransport is the original JSON-over-HTTP Transport, using the routes
+// registered in router to turn a route name into a URL.
+type httpTransport struct {
+	client      *http.Client
+	token       flux.Token
+	router      *mux.Router
+	endpoint    string
+	retryPolicy RetryPolicy
+}
+
+func (t *httpTransport) Invoke(ctx context.Context, inst flux.InstanceID, method, route string, params url.Values, body, dest interface{}) error {
+	u, err := makeURL(t.endpoint, t.router, route, paramPairs(params)...)
+	if err != nil {
+		return errors.Wrap(err, "constructing URL")
+	}
+
+	var bodyBytes []byte
+	if body != nil {
+		bodyBytes, err = json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "encoding request body")
+		}
+	}
+
+	// A POST is only safe to retry if the server can recognize and dedupe
+	// it; a single key generated here and sent on every attempt lets it do
+	// that, since retries of the same logical request reuse it.
+	var idempotencyKey string
+	if method != "GET" {
+		idempotencyKey = newIdempotencyKey()
+	}
+
+	var lastErr error
+	attempts := t.retryPolicy.attempts()
+	for attempt := 0; attempt < attempts; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(bodyBytes))
+		if err != nil {
+			return errors.Wrapf(err, "constructing request %s", u)
+		}
+		t.token.Set(req)
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+
+		resp, err := t.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return errors.Wrap(ctx.Err(), "executing HTTP request")
+			}
+			lastErr = errors.Wrap(err, "executing HTTP request")
+			if err := t.sleepBeforeRetry(ctx, attempt, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return decodeResponse(resp, dest)
+		}
+
+		buf, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		decoded := httperror.Decode(resp.StatusCode, resp.Status, bytes.TrimSpace(buf))
+
+		if attempt < attempts-1 && isRetryableStatus(resp.StatusCode) {
+			lastErr = decoded
+			if err := t.sleepBeforeRetry(ctx, attempt, resp); err != nil {
+				return err
+			}
+			continue
+		}
+
+		return decoded
+	}
+	return lastErr
+}
+
+// sleepBeforeRetry waits out either the server's requested Retry-After, or
+// our own full-jitter backoff if there wasn't one -- unless ctx is canceled
+// or times out first, in which case it returns immediately with ctx's
+// error, so a caller's deadline takes effect mid-backoff rather than only
+// between requests.
+func (t *httpTransport) sleepBeforeRetry(ctx context.Context, attempt int, resp *http.Response) error {
+	d := t.retryPolicy.backoff(attempt)
+	if resp != nil {
+		if after, ok := retryAfter(resp); ok {
+			d = after
+		}
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "executing HTTP request")
+	}
+}
+
+func decodeResponse(resp *http.Response, dest interface{}) error {
+	defer resp.Body.Close()
+	if dest == nil {
+		return nil
+	}
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "decoding response from server")
+	}
+	if len(respBytes) <= 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBytes, dest); err != nil {
+		return errors.Wrap(err, "decoding response from server")
+	}
+	return nil
+}
+
+func (t *httpTransport) Stream(ctx context.Context, inst flux.InstanceID, route string, params url.Values, resumeToken string) (io.ReadCloser, error) {
+	u, err := makeURL(t.endpoint, t.router, route, paramPairs(params)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "constructing URL")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "constructing request %s", u)
+	}
+	t.token.Set(req)
+	if resumeToken != "" {
+		req.Header.Set(resumeTokenHeader, resumeToken)
+	}
+
+	resp, err := t.do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "executing watch request")
+	}
+	return resp.Body, nil
+}
+
+func (t *httpTransport) do(req *http.Request) (*http.Response, error) {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "executing HTTP request")
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	default:
+		buf, _ := ioutil.ReadAll(resp.Body)
+		return nil, httperror.Decode(resp.StatusCode, resp.Status, bytes.TrimSpace(buf))
+	}
+}
+
+// paramPairs flattens a url.Values back into the alternating key/value
+// pairs makeURL expects. A key with several values (e.g. PostRelease's
+// repeated "service" and "exclude") yields one pair per value.
+func paramPairs(params url.Values) []string {
+	pairs := make([]string, 0, len(params)*2)
+	for k, vs := range params {
+		for _, v := range vs {
+			pairs = append(pairs, k, v)
+		}
+	}
+	return pairs
+}
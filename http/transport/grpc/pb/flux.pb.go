@@ -0,0 +1,292 @@
+// Package pb is hand-maintained, not protoc output: it's written in the
+// shape protoc-gen-go (the legacy github.com/golang/protobuf generator)
+// would produce from flux.proto, so that `make proto` can become a real
+// regeneration step later without changing this package's API, but running
+// `protoc --go_out=plugins=grpc:pb flux.proto` today will NOT reproduce
+// this file byte-for-byte -- it's missing the compressed file descriptor
+// and proto.RegisterFile call real generated output carries. Keep this
+// file and flux.proto in sync by hand until a protoc toolchain is wired
+// into the build.
+// source: flux.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type InvokeRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`
+	Method     string            `protobuf:"bytes,2,opt,name=method,proto3" json:"method,omitempty"`
+	Route      string            `protobuf:"bytes,3,opt,name=route,proto3" json:"route,omitempty"`
+	Params     map[string]string `protobuf:"bytes,4,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Body       []byte            `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+}
+
+func (m *InvokeRequest) Reset()         { *m = InvokeRequest{} }
+func (m *InvokeRequest) String() string { return proto.CompactTextString(m) }
+func (*InvokeRequest) ProtoMessage()    {}
+
+func (m *InvokeRequest) GetInstanceId() string {
+	if m != nil {
+		return m.InstanceId
+	}
+	return ""
+}
+
+func (m *InvokeRequest) GetMethod() string {
+	if m != nil {
+		return m.Method
+	}
+	return ""
+}
+
+func (m *InvokeRequest) GetRoute() string {
+	if m != nil {
+		return m.Route
+	}
+	return ""
+}
+
+func (m *InvokeRequest) GetParams() map[string]string {
+	if m != nil {
+		return m.Params
+	}
+	return nil
+}
+
+func (m *InvokeRequest) GetBody() []byte {
+	if m != nil {
+		return m.Body
+	}
+	return nil
+}
+
+type InvokeResponse struct {
+	Body []byte `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+}
+
+func (m *InvokeResponse) Reset()         { *m = InvokeResponse{} }
+func (m *InvokeResponse) String() string { return proto.CompactTextString(m) }
+func (*InvokeResponse) ProtoMessage()    {}
+
+func (m *InvokeResponse) GetBody() []byte {
+	if m != nil {
+		return m.Body
+	}
+	return nil
+}
+
+type StreamRequest struct {
+	InstanceId  string            `protobuf:"bytes,1,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`
+	Route       string            `protobuf:"bytes,2,opt,name=route,proto3" json:"route,omitempty"`
+	Params      map[string]string `protobuf:"bytes,3,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	ResumeToken string            `protobuf:"bytes,4,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+}
+
+func (m *StreamRequest) Reset()         { *m = StreamRequest{} }
+func (m *StreamRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamRequest) ProtoMessage()    {}
+
+func (m *StreamRequest) GetInstanceId() string {
+	if m != nil {
+		return m.InstanceId
+	}
+	return ""
+}
+
+func (m *StreamRequest) GetRoute() string {
+	if m != nil {
+		return m.Route
+	}
+	return ""
+}
+
+func (m *StreamRequest) GetParams() map[string]string {
+	if m != nil {
+		return m.Params
+	}
+	return nil
+}
+
+func (m *StreamRequest) GetResumeToken() string {
+	if m != nil {
+		return m.ResumeToken
+	}
+	return ""
+}
+
+type StreamEvent struct {
+	Body []byte `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+}
+
+func (m *StreamEvent) Reset()         { *m = StreamEvent{} }
+func (m *StreamEvent) String() string { return proto.CompactTextString(m) }
+func (*StreamEvent) ProtoMessage()    {}
+
+func (m *StreamEvent) GetBody() []byte {
+	if m != nil {
+		return m.Body
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*InvokeRequest)(nil), "flux.InvokeRequest")
+	proto.RegisterType((*InvokeResponse)(nil), "flux.InvokeResponse")
+	proto.RegisterType((*StreamRequest)(nil), "flux.StreamRequest")
+	proto.RegisterType((*StreamEvent)(nil), "flux.StreamEvent")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// FluxServiceClient is the client API for FluxService service.
+type FluxServiceClient interface {
+	Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error)
+	Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (FluxService_StreamClient, error)
+}
+
+type fluxServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewFluxServiceClient(cc *grpc.ClientConn) FluxServiceClient {
+	return &fluxServiceClient{cc}
+}
+
+func (c *fluxServiceClient) Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error) {
+	out := new(InvokeResponse)
+	err := c.cc.Invoke(ctx, "/flux.FluxService/Invoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fluxServiceClient) Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (FluxService_StreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_FluxService_serviceDesc.Streams[0], "/flux.FluxService/Stream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &fluxServiceStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// FluxService_StreamClient is the client-side stream handle returned by
+// Stream: one StreamEvent per Recv, in the order the server sent them.
+type FluxService_StreamClient interface {
+	Recv() (*StreamEvent, error)
+	grpc.ClientStream
+}
+
+type fluxServiceStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *fluxServiceStreamClient) Recv() (*StreamEvent, error) {
+	m := new(StreamEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FluxServiceServer is the server API for FluxService service.
+type FluxServiceServer interface {
+	Invoke(context.Context, *InvokeRequest) (*InvokeResponse, error)
+	Stream(*StreamRequest, FluxService_StreamServer) error
+}
+
+// UnimplementedFluxServiceServer can be embedded to have forward compatible
+// implementations; it returns Unimplemented for any method not overridden.
+type UnimplementedFluxServiceServer struct{}
+
+func (*UnimplementedFluxServiceServer) Invoke(context.Context, *InvokeRequest) (*InvokeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Invoke not implemented")
+}
+func (*UnimplementedFluxServiceServer) Stream(*StreamRequest, FluxService_StreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method Stream not implemented")
+}
+
+func RegisterFluxServiceServer(s *grpc.Server, srv FluxServiceServer) {
+	s.RegisterService(&_FluxService_serviceDesc, srv)
+}
+
+func _FluxService_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FluxServiceServer).Invoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/flux.FluxService/Invoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FluxServiceServer).Invoke(ctx, req.(*InvokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FluxService_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FluxServiceServer).Stream(m, &fluxServiceStreamServer{stream})
+}
+
+// FluxService_StreamServer is the server-side stream handle passed to a
+// FluxServiceServer's Stream method.
+type FluxService_StreamServer interface {
+	Send(*StreamEvent) error
+	grpc.ServerStream
+}
+
+type fluxServiceStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *fluxServiceStreamServer) Send(m *StreamEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _FluxService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "flux.FluxService",
+	HandlerType: (*FluxServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Invoke",
+			Handler:    _FluxService_Invoke_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			Handler:       _FluxService_Stream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "flux.proto",
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/jobs"
+)
+
+// watchReconnectDelay is how long we wait before re-establishing a dropped
+// watch connection.
+const watchReconnectDelay = 2 * time.Second
+
+// resumeTokenHeader carries the last-seen event token back to the server so
+// it can resume a watch stream from where it left off, rather than replaying
+// the whole history or missing events sent while we were reconnecting.
+const resumeTokenHeader = "X-Flux-Resume-Token"
+
+// watchEvent is the newline-delimited JSON envelope sent down a watch
+// stream. The token is opaque to the client; it is only ever echoed back via
+// resumeTokenHeader to resume after a dropped connection.
+type watchEvent struct {
+	Token  string         `json:"token"`
+	Status jobs.JobStatus `json:"status"`
+}
+
+// WatchReleaseCtx subscribes to progress updates for the given release job,
+// rather than forcing the caller to poll GetRelease. The returned channel is
+// closed when the job reaches a terminal state or ctx is canceled; any
+// failure to open or read the stream is retried, resuming from the last
+// token seen, every watchReconnectDelay until then. There is deliberately no context-free
+// WatchRelease: event delivery blocks on sending to the returned channel
+// (see streamRelease below), and a receiver has no way to close a channel
+// it no longer wants to read from, so ctx is the only thing that can stop
+// the underlying connection and goroutine once a caller loses interest.
+func (c *client) WatchReleaseCtx(ctx context.Context, inst flux.InstanceID, id jobs.JobID) (<-chan jobs.JobStatus, error) {
+	ch := make(chan jobs.JobStatus)
+	go c.watchRelease(ctx, inst, id, ch)
+	return ch, nil
+}
+
+func (c *client) watchRelease(ctx context.Context, inst flux.InstanceID, id jobs.JobID, ch chan<- jobs.JobStatus) {
+	defer close(ch)
+
+	var resumeToken string
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		done, err := c.streamRelease(ctx, inst, id, resumeToken, ch, &resumeToken)
+		if done {
+			return
+		}
+		if err != nil {
+			select {
+			case <-time.After(watchReconnectDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// streamRelease opens a single watch connection and reads events from it
+// until the connection ends. It reports done=true once the server has
+// indicated there is nothing further to send.
+func (c *client) streamRelease(ctx context.Context, inst flux.InstanceID, id jobs.JobID, resumeToken string, ch chan<- jobs.JobStatus, lastToken *string) (done bool, err error) {
+	params := url.Values{}
+	params.Set("id", string(id))
+
+	body, err := c.transport.Stream(ctx, inst, "WatchRelease", params, resumeToken)
+	if err != nil {
+		return false, errors.Wrap(err, "opening watch stream")
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var ev watchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return false, errors.Wrap(err, "decoding watch event")
+		}
+		*lastToken = ev.Token
+		select {
+		case ch <- ev.Status:
+		case <-ctx.Done():
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		// The connection dropped mid-stream; reconnect using the last token
+		// we saw so the server can resume from there.
+		return false, err
+	}
+	// The server closes the connection once the job reaches a terminal
+	// state; a clean EOF means there is nothing left to watch for.
+	return true, nil
+}